@@ -0,0 +1,79 @@
+// Package hashring implements a consistent hash ring used to shard keys
+// across a fixed set of named nodes.
+package hashring
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// virtualNodes is the number of points placed on the ring per node. A higher
+// count spreads the keyspace more evenly across nodes at the cost of more
+// memory and a slightly slower binary search.
+const virtualNodes = 160
+
+// Ring is a consistent hash ring over the uint32 keyspace. It is not safe
+// for concurrent use; callers must guard Rebuild/Locate with their own lock
+// if nodes can change while lookups are in flight.
+type Ring struct {
+	points []point
+}
+
+type point struct {
+	hash uint32
+	node string
+}
+
+// New builds a Ring containing every node in nodes.
+func New(nodes []string) *Ring {
+	r := &Ring{}
+	r.Rebuild(nodes)
+	return r
+}
+
+// Rebuild recomputes the ring's virtual nodes from scratch, replacing any
+// previous contents. Use it to add or remove nodes.
+func (r *Ring) Rebuild(nodes []string) {
+	points := make([]point, 0, len(nodes)*virtualNodes)
+	for _, node := range nodes {
+		for i := 0; i < virtualNodes; i++ {
+			points = append(points, point{hash: hashKey(node + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+	r.points = points
+}
+
+// Locate returns the node owning key, or "" if the ring is empty. Keys that
+// share a `{tag}` hashtag, as used to co-locate cluster slots, always
+// locate to the same node.
+func (r *Ring) Locate(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hashKey(Hashtag(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// Hashtag returns the portion of key that should be hashed: the contents
+// between the first '{' and the next '}' if that is non-empty, otherwise
+// key itself (later `{...}` pairs are not considered).
+// This mirrors the hashtag rule Redis Cluster uses for CLUSTER KEYSLOT.
+func Hashtag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+func hashKey(key string) uint32 {
+	return crc32.ChecksumIEEE([]byte(key))
+}
@@ -0,0 +1,65 @@
+package hashring
+
+import "testing"
+
+func TestHashtag(t *testing.T) {
+	cases := []struct {
+		key  string
+		want string
+	}{
+		{"foo", "foo"},
+		{"{user}:1", "user"},
+		{"{user}:2", "user"},
+		{"a{user}b", "user"},
+		{"{}", "{}"},
+		{"{unbalanced", "{unbalanced"},
+		{"no}braces", "no}braces"},
+	}
+	for _, c := range cases {
+		if got := Hashtag(c.key); got != c.want {
+			t.Errorf("Hashtag(%q) = %q, want %q", c.key, got, c.want)
+		}
+	}
+}
+
+func TestRingSameHashtagSameNode(t *testing.T) {
+	r := New([]string{"a", "b", "c", "d"})
+	n1 := r.Locate("{user}:1")
+	n2 := r.Locate("{user}:2")
+	if n1 != n2 {
+		t.Fatalf("keys sharing a hashtag landed on different nodes: %q vs %q", n1, n2)
+	}
+}
+
+func TestRingEmpty(t *testing.T) {
+	r := New(nil)
+	if got := r.Locate("any"); got != "" {
+		t.Fatalf("Locate on empty ring = %q, want \"\"", got)
+	}
+}
+
+func TestRingRebuildOnlyMovesFraction(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d"}
+	r := New(nodes)
+	keys := make([]string, 1000)
+	before := make(map[string]string, len(keys))
+	for i := range keys {
+		keys[i] = string(rune('a' + i%26))
+		for j := 0; j < i/26; j++ {
+			keys[i] += string(rune('a' + j%26))
+		}
+		before[keys[i]] = r.Locate(keys[i])
+	}
+
+	r.Rebuild(append(nodes, "e"))
+
+	moved := 0
+	for _, k := range keys {
+		if r.Locate(k) != before[k] {
+			moved++
+		}
+	}
+	if moved == 0 || moved == len(keys) {
+		t.Fatalf("expected only a fraction of keys to move after adding a node, moved %d/%d", moved, len(keys))
+	}
+}
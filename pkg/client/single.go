@@ -1,6 +1,8 @@
 package client
 
 import (
+	"context"
+	"strconv"
 	"time"
 
 	"github.com/rueian/rueidis/internal/cmds"
@@ -12,11 +14,16 @@ import (
 type SingleClientOption struct {
 	Address    string
 	ConnOption conn.Option
+	// Hooks wrap every command dispatched through Do, DoCache, DoMulti,
+	// DoMultiCache and DedicatedWire, composed in registration order (see
+	// conn.Hooks).
+	Hooks []conn.Hook
 }
 
 type SingleClient struct {
 	Cmd  *cmds.Builder
 	conn *conn.Conn
+	hook conn.Hook
 }
 
 func NewSingleClient(option SingleClientOption) (*SingleClient, error) {
@@ -24,50 +31,222 @@ func NewSingleClient(option SingleClientOption) (*SingleClient, error) {
 	if err := c.Dialable(); err != nil {
 		return nil, err
 	}
-	return &SingleClient{Cmd: cmds.NewBuilder(), conn: c}, nil
+	return &SingleClient{Cmd: cmds.NewBuilder(), conn: c, hook: conn.Hooks(option.Hooks...)}, nil
 }
 
 func (c *SingleClient) Info() map[string]proto.Message {
 	return c.conn.Info()
 }
 
-func (c *SingleClient) Do(cmd cmds.Completed) (resp proto.Result) {
-	resp = c.conn.Do(cmd)
+// Dialable reports whether the underlying connection can currently reach
+// Redis, returning the dial error otherwise.
+func (c *SingleClient) Dialable() error {
+	return c.conn.Dialable()
+}
+
+// Do sends cmd to the underlying connection and blocks until a response is
+// received or ctx is done. If ctx is canceled or its deadline is exceeded
+// before the response arrives, Do returns a result wrapping ctx.Err() and
+// the in-flight request is abandoned on the reader loop. Any Hooks from
+// SingleClientOption run before and after the dispatch; BeforeProcess may
+// replace ctx (e.g. to attach a span) but, since Do predates hooks and has
+// no error return of its own, a BeforeProcess error is not surfaced here
+// (neither built-in hook ever returns one).
+func (c *SingleClient) Do(ctx context.Context, cmd cmds.Completed) (resp proto.Result) {
+	ctx, _ = c.hook.BeforeProcess(ctx, cmd)
+	resp = c.conn.Do(ctx, cmd)
+	c.hook.AfterProcess(ctx, cmd, resp)
 	c.Cmd.Put(cmd.Commands())
 	return resp
 }
 
-func (c *SingleClient) DoCache(cmd cmds.Cacheable, ttl time.Duration) (resp proto.Result) {
-	resp = c.conn.DoCache(cmd, ttl)
+// DoCache behaves like Do but first consults the client-side cache. A ctx
+// cancellation is treated as a cache miss so a canceled lookup never marks
+// or pollutes an entry in the client-side cache. ctx is marked with
+// conn.WithCacheable so a Hook can tell this dispatch apart from a plain Do.
+func (c *SingleClient) DoCache(ctx context.Context, cmd cmds.Cacheable, ttl time.Duration) (resp proto.Result) {
+	ctx = conn.WithCacheable(ctx)
+	ctx, _ = c.hook.BeforeProcess(ctx, cmds.Completed(cmd))
+	resp = c.conn.DoCache(ctx, cmd, ttl)
+	c.hook.AfterProcess(ctx, cmds.Completed(cmd), resp)
 	c.Cmd.Put(cmd.Commands())
 	return resp
 }
 
-func (c *SingleClient) DedicatedWire(fn func(DedicatedSingleClient) error) (err error) {
+// CacheableTTL pairs a cacheable command with the TTL it should be cached
+// for, used by DoMultiCache to pipeline several client-side-cached reads in
+// a single round-trip.
+type CacheableTTL struct {
+	Cmd cmds.Cacheable
+	TTL time.Duration
+}
+
+// DoMulti queues cmds onto the shared connection's pipelined writer and
+// returns their responses in the same order, without acquiring a dedicated
+// wire. Unlike DedicatedSingleClient.DoMulti, the connection remains free
+// for other callers between commands.
+func (c *SingleClient) DoMulti(ctx context.Context, multi ...cmds.Completed) (resp []proto.Result) {
+	if len(multi) == 0 {
+		return nil
+	}
+	ctx, _ = c.hook.BeforeProcessPipeline(ctx, multi)
+	resp = c.conn.DoMulti(ctx, multi...)
+	c.hook.AfterProcessPipeline(ctx, multi, resp)
+	for _, cmd := range multi {
+		c.Cmd.Put(cmd.Commands())
+	}
+	return resp
+}
+
+// DoMultiCache behaves like DoMulti but serves/fills the client-side cache
+// for each pair, coalescing the misses into a single flush.
+func (c *SingleClient) DoMultiCache(ctx context.Context, pairs ...CacheableTTL) (resp []proto.Result) {
+	if len(pairs) == 0 {
+		return nil
+	}
+	cacheables := make([]cmds.Cacheable, len(pairs))
+	ttls := make([]time.Duration, len(pairs))
+	completed := make([]cmds.Completed, len(pairs))
+	for i, p := range pairs {
+		cacheables[i], ttls[i] = p.Cmd, p.TTL
+		completed[i] = cmds.Completed(p.Cmd)
+	}
+	ctx = conn.WithCacheable(ctx)
+	ctx, _ = c.hook.BeforeProcessPipeline(ctx, completed)
+	resp = c.conn.DoMultiCache(ctx, cacheables, ttls)
+	c.hook.AfterProcessPipeline(ctx, completed, resp)
+	for _, p := range pairs {
+		c.Cmd.Put(p.Cmd.Commands())
+	}
+	return resp
+}
+
+// DedicatedWire acquires an exclusive wire for fn to issue a sequence of
+// commands on, releasing it back to the pool afterwards. The wire is closed
+// if ctx is done before fn returns, unblocking any command still waiting on
+// Redis.
+func (c *SingleClient) DedicatedWire(ctx context.Context, fn func(DedicatedSingleClient) error) (err error) {
 	wire := c.conn.Acquire()
-	err = fn(DedicatedSingleClient{cmd: c.Cmd, wire: wire})
-	c.conn.Store(wire)
+	done := make(chan struct{})
+	closedByCtx := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			wire.Close()
+			closedByCtx <- true
+		case <-done:
+			closedByCtx <- false
+		}
+	}()
+	err = fn(DedicatedSingleClient{cmd: c.Cmd, wire: wire, hook: c.hook})
+	close(done)
+	// Only return the wire to the pool if ctx cancellation didn't close it
+	// out from under us; otherwise the next Acquire would hand out a dead
+	// connection.
+	if !<-closedByCtx {
+		c.conn.Store(wire)
+	}
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
 	return err
 }
 
+// NewHashRepository returns an om.HashRepository that stores schema under
+// prefix using HSET/HGETALL/DEL. If schema declares `search` struct tags,
+// a RediSearch index is created over it (ON HASH) and the repository's
+// Search method is wired to FT.SEARCH.
 func (c *SingleClient) NewHashRepository(prefix string, schema interface{}) *om.HashRepository {
 	return om.NewHashRepository(
 		prefix,
 		schema,
-		func(key string, fields map[string]string) (ver int64, err error) {
+		func(ctx context.Context, key string, fields map[string]string) (ver int64, err error) {
 			cmd := c.Cmd.Hset().Key(key).FieldValue()
 			for f, v := range fields {
 				cmd = cmd.FieldValue(f, v)
 			}
-			_, err = c.Do(cmd.Build()).ToInt64()
+			_, err = c.Do(ctx, cmd.Build()).ToInt64()
 			return
 		},
-		func(key string) (map[string]proto.Message, error) {
-			return c.Do(c.Cmd.Hgetall().Key(key).Build()).ToMap()
+		func(ctx context.Context, key string) (map[string]proto.Message, error) {
+			return c.Do(ctx, c.Cmd.Hgetall().Key(key).Build()).ToMap()
+		},
+		func(ctx context.Context, key string, ttl time.Duration) (map[string]proto.Message, error) {
+			return c.DoCache(ctx, c.Cmd.Hgetall().Key(key).Cache(), ttl).ToMap()
 		},
-		func(key string, ttl time.Duration) (map[string]proto.Message, error) {
-			return c.DoCache(c.Cmd.Hgetall().Key(key).Cache(), ttl).ToMap()
-		})
+		func(ctx context.Context, key string) (int64, error) {
+			return c.Do(ctx, c.Cmd.Del().Key(key).Build()).ToInt64()
+		},
+		c.searchCloser(prefix, schema, "HASH"))
+}
+
+// NewJSONRepository returns an om.JSONRepository that stores schema under
+// prefix using RedisJSON's JSON.SET/JSON.GET/JSON.DEL commands. If schema
+// declares `search` struct tags, a RediSearch index is created over it (ON
+// JSON) and the repository's Search method is wired to FT.SEARCH.
+func (c *SingleClient) NewJSONRepository(prefix string, schema interface{}) *om.JSONRepository {
+	return om.NewJSONRepository(
+		prefix,
+		schema,
+		func(ctx context.Context, key, path, value string) error {
+			return c.Do(ctx, c.Cmd.JsonSet().Key(key).Path(path).Value(value).Build()).Error()
+		},
+		func(ctx context.Context, key string) (string, error) {
+			return c.Do(ctx, c.Cmd.JsonGet().Key(key).Build()).ToString()
+		},
+		func(ctx context.Context, key string) (int64, error) {
+			return c.Do(ctx, c.Cmd.JsonDel().Key(key).Build()).ToInt64()
+		},
+		c.searchCloser(prefix, schema, "JSON"))
+}
+
+// searchCloser issues FT.CREATE for schema's `search` tags (if any) over
+// storage ("HASH" or "JSON") and returns a closure that runs FT.SEARCH
+// against that index, for use as a repository's doSearch. It returns nil
+// if schema declares no `search` tags, leaving the repository's Search
+// method disabled.
+func (c *SingleClient) searchCloser(prefix string, schema interface{}, storage string) func(ctx context.Context, query string, opt om.SearchOption) (int64, []string, error) {
+	if !om.HasSearchFields(schema) {
+		return nil
+	}
+	indexName := "idx:" + prefix
+	args := append([]string{"FT.CREATE"}, om.CreateArgs(indexName, storage, schema, om.IndexOption{Prefix: prefix})...)
+	c.Do(context.Background(), c.Cmd.Arbitrary(args...).Build())
+
+	return func(ctx context.Context, query string, opt om.SearchOption) (int64, []string, error) {
+		cmd := c.Cmd.Arbitrary("FT.SEARCH", indexName, query,
+			"LIMIT", strconv.Itoa(opt.Offset), strconv.Itoa(opt.Limit)).Build()
+		return parseSearchReply(c.Do(ctx, cmd))
+	}
+}
+
+// parseSearchReply decodes an FT.SEARCH reply of the form
+// [total, id1, [path, doc1, ...], id2, [path, doc2, ...], ...] into the
+// total match count and the raw JSON document bodies.
+func parseSearchReply(resp proto.Result) (int64, []string, error) {
+	if err := resp.Error(); err != nil {
+		return 0, nil, err
+	}
+	arr, err := resp.ToArray()
+	if err != nil || len(arr) == 0 {
+		return 0, nil, err
+	}
+	total, _ := arr[0].ToInt64()
+	docs := make([]string, 0, (len(arr)-1)/2)
+	for i := 1; i+1 < len(arr); i += 2 {
+		fields, err := arr[i+1].ToArray()
+		if err != nil {
+			continue
+		}
+		for j := 0; j+1 < len(fields); j += 2 {
+			if name, _ := fields[j].ToString(); name == "$" {
+				if val, err := fields[j+1].ToString(); err == nil {
+					docs = append(docs, val)
+				}
+			}
+		}
+	}
+	return total, docs, nil
 }
 
 func (c *SingleClient) Close() {
@@ -77,21 +256,31 @@ func (c *SingleClient) Close() {
 type DedicatedSingleClient struct {
 	cmd  *cmds.Builder
 	wire conn.Wire
+	hook conn.Hook
 }
 
-func (c *DedicatedSingleClient) Do(cmd cmds.Completed) (resp proto.Result) {
-	resp = c.wire.Do(cmd)
+// Do sends cmd on the dedicated wire, returning early with a ctx.Err result
+// if ctx is done before the response arrives. It runs the owning
+// SingleClient's Hooks the same way SingleClient.Do does.
+func (c *DedicatedSingleClient) Do(ctx context.Context, cmd cmds.Completed) (resp proto.Result) {
+	ctx, _ = c.hook.BeforeProcess(ctx, cmd)
+	resp = c.wire.Do(ctx, cmd)
+	c.hook.AfterProcess(ctx, cmd, resp)
 	c.cmd.Put(cmd.Commands())
 	return resp
 }
 
-func (c *DedicatedSingleClient) DoMulti(multi ...cmds.Completed) (resp []proto.Result) {
+// DoMulti behaves like Do but pipelines multi as a single round-trip,
+// returning early if ctx is done before all responses arrive.
+func (c *DedicatedSingleClient) DoMulti(ctx context.Context, multi ...cmds.Completed) (resp []proto.Result) {
 	if len(multi) == 0 {
 		return nil
 	}
-	resp = c.wire.DoMulti(multi...)
+	ctx, _ = c.hook.BeforeProcessPipeline(ctx, multi)
+	resp = c.wire.DoMulti(ctx, multi...)
+	c.hook.AfterProcessPipeline(ctx, multi, resp)
 	for _, cmd := range multi {
 		c.cmd.Put(cmd.Commands())
 	}
 	return resp
-}
\ No newline at end of file
+}
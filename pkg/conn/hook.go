@@ -0,0 +1,94 @@
+// Package conn holds the low level connection used by the higher level
+// clients in pkg/client, pkg/ring and pkg/cluster. This file adds the Hook
+// extension point; the Conn/Option/Wire types themselves live alongside the
+// rest of the connection implementation.
+package conn
+
+import (
+	"context"
+
+	"github.com/rueian/rueidis/internal/cmds"
+	"github.com/rueian/rueidis/internal/proto"
+)
+
+// Hook wraps the execution of commands so cross-cutting concerns such as
+// tracing, metrics and retries can be layered onto a Conn without patching
+// it. Hooks are set via Option.Hooks and compose in registration order: the
+// first hook's BeforeProcess runs first and its AfterProcess runs last,
+// like net/http middleware.
+type Hook interface {
+	// BeforeProcess runs before a command (or, for BeforeProcessPipeline,
+	// a batch of commands) is written to the wire. It may replace ctx,
+	// e.g. to attach a span. An error it returns stops the rest of a Hooks
+	// chain's BeforeProcess calls; the clients in pkg/client discard that
+	// error and still send the command.
+	BeforeProcess(ctx context.Context, cmd cmds.Completed) (context.Context, error)
+	// AfterProcess runs once resp has been read back for cmd.
+	AfterProcess(ctx context.Context, cmd cmds.Completed, resp proto.Result) error
+	// BeforeProcessPipeline is the DoMulti counterpart of BeforeProcess.
+	BeforeProcessPipeline(ctx context.Context, cmds []cmds.Completed) (context.Context, error)
+	// AfterProcessPipeline is the DoMulti counterpart of AfterProcess.
+	AfterProcessPipeline(ctx context.Context, cmds []cmds.Completed, resp []proto.Result) error
+}
+
+// Hooks chains multiple Hook implementations into a single Hook, running
+// them in registration order on the way in and reverse order on the way
+// out.
+func Hooks(hooks ...Hook) Hook {
+	return chain(hooks)
+}
+
+type chain []Hook
+
+func (c chain) BeforeProcess(ctx context.Context, cmd cmds.Completed) (context.Context, error) {
+	var err error
+	for _, h := range c {
+		if ctx, err = h.BeforeProcess(ctx, cmd); err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+func (c chain) AfterProcess(ctx context.Context, cmd cmds.Completed, resp proto.Result) error {
+	for i := len(c) - 1; i >= 0; i-- {
+		if err := c[i].AfterProcess(ctx, cmd, resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c chain) BeforeProcessPipeline(ctx context.Context, cs []cmds.Completed) (context.Context, error) {
+	var err error
+	for _, h := range c {
+		if ctx, err = h.BeforeProcessPipeline(ctx, cs); err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+func (c chain) AfterProcessPipeline(ctx context.Context, cs []cmds.Completed, resp []proto.Result) error {
+	for i := len(c) - 1; i >= 0; i-- {
+		if err := c[i].AfterProcessPipeline(ctx, cs, resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type cacheableKey struct{}
+
+// WithCacheable marks ctx as wrapping a DoCache/DoMultiCache dispatch, so a
+// Hook such as rueidishook's MetricsHook can tell a client-side cache lookup
+// apart from a plain Do call when counting hits/misses.
+func WithCacheable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheableKey{}, true)
+}
+
+// IsCacheable reports whether ctx was marked by WithCacheable.
+func IsCacheable(ctx context.Context) bool {
+	v, _ := ctx.Value(cacheableKey{}).(bool)
+	return v
+}
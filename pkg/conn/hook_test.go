@@ -0,0 +1,108 @@
+package conn
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rueian/rueidis/internal/cmds"
+	"github.com/rueian/rueidis/internal/proto"
+)
+
+type recordingHook struct {
+	name string
+	log  *[]string
+}
+
+func (h recordingHook) BeforeProcess(ctx context.Context, _ cmds.Completed) (context.Context, error) {
+	*h.log = append(*h.log, "before:"+h.name)
+	return ctx, nil
+}
+
+func (h recordingHook) AfterProcess(_ context.Context, _ cmds.Completed, _ proto.Result) error {
+	*h.log = append(*h.log, "after:"+h.name)
+	return nil
+}
+
+func (h recordingHook) BeforeProcessPipeline(ctx context.Context, _ []cmds.Completed) (context.Context, error) {
+	*h.log = append(*h.log, "before:"+h.name)
+	return ctx, nil
+}
+
+func (h recordingHook) AfterProcessPipeline(_ context.Context, _ []cmds.Completed, _ []proto.Result) error {
+	*h.log = append(*h.log, "after:"+h.name)
+	return nil
+}
+
+func TestHooksComposeLikeMiddleware(t *testing.T) {
+	var log []string
+	chain := Hooks(
+		recordingHook{name: "a", log: &log},
+		recordingHook{name: "b", log: &log},
+	)
+
+	ctx, err := chain.BeforeProcess(context.Background(), cmds.Completed{})
+	if err != nil {
+		t.Fatalf("BeforeProcess returned an error: %v", err)
+	}
+	if err := chain.AfterProcess(ctx, cmds.Completed{}, proto.Result{}); err != nil {
+		t.Fatalf("AfterProcess returned an error: %v", err)
+	}
+
+	want := []string{"before:a", "before:b", "after:b", "after:a"}
+	if len(log) != len(want) {
+		t.Fatalf("log = %v, want %v", log, want)
+	}
+	for i := range want {
+		if log[i] != want[i] {
+			t.Fatalf("log = %v, want %v", log, want)
+		}
+	}
+}
+
+type abortingHook struct{}
+
+var errAborted = context.Canceled
+
+func (abortingHook) BeforeProcess(ctx context.Context, _ cmds.Completed) (context.Context, error) {
+	return ctx, errAborted
+}
+func (abortingHook) AfterProcess(context.Context, cmds.Completed, proto.Result) error { return nil }
+func (abortingHook) BeforeProcessPipeline(ctx context.Context, _ []cmds.Completed) (context.Context, error) {
+	return ctx, errAborted
+}
+func (abortingHook) AfterProcessPipeline(context.Context, []cmds.Completed, []proto.Result) error {
+	return nil
+}
+
+func TestHooksBeforeProcessShortCircuitsOnError(t *testing.T) {
+	var log []string
+	chain := Hooks(recordingHook{name: "a", log: &log}, abortingHook{}, recordingHook{name: "b", log: &log})
+
+	_, err := chain.BeforeProcess(context.Background(), cmds.Completed{})
+	if err != errAborted {
+		t.Fatalf("BeforeProcess error = %v, want %v", err, errAborted)
+	}
+	if len(log) != 1 || log[0] != "before:a" {
+		t.Fatalf("log = %v, want a hook after the abort to never run", log)
+	}
+}
+
+func TestEmptyChainIsNoop(t *testing.T) {
+	chain := Hooks()
+	if _, err := chain.BeforeProcess(context.Background(), cmds.Completed{}); err != nil {
+		t.Fatalf("empty chain BeforeProcess returned an error: %v", err)
+	}
+	if err := chain.AfterProcess(context.Background(), cmds.Completed{}, proto.Result{}); err != nil {
+		t.Fatalf("empty chain AfterProcess returned an error: %v", err)
+	}
+}
+
+func TestCacheableContext(t *testing.T) {
+	ctx := context.Background()
+	if IsCacheable(ctx) {
+		t.Fatal("a plain context should not be cacheable")
+	}
+	if !IsCacheable(WithCacheable(ctx)) {
+		t.Fatal("WithCacheable should mark the context as cacheable")
+	}
+}
@@ -0,0 +1,211 @@
+// Package lock implements a single-instance distributed lock on top of a
+// Redis client, following the standard SET NX PX / compare-and-delete
+// pattern, with an optional monotonic fencing token for callers that write
+// to external stores and need to reject stale writers.
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rueian/rueidis/pkg/client"
+)
+
+// ErrNotObtained is returned by Obtain when the lock could not be acquired
+// and the Locker's RetryStrategy is nil or gives up. If ctx is done while
+// Obtain waits between attempts, it returns ctx.Err() instead.
+var ErrNotObtained = errors.New("lock: not obtained")
+
+// release compares the stored token before deleting the key, so a caller
+// can never release a lock it does not hold (e.g. after its TTL expired
+// and another caller obtained it).
+const release = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`
+
+// refresh compares the stored token before extending the key's TTL.
+const refresh = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`
+
+// cachedScript evaluates a Lua script via EVALSHA, caching its SHA behind a
+// mutex and reloading it with SCRIPT LOAD on a NOSCRIPT reply, the same
+// pattern pkg/rate's Limiter uses for its leaky-bucket script.
+type cachedScript struct {
+	client *client.SingleClient
+	script string
+
+	mu  sync.RWMutex
+	sha string
+}
+
+func (s *cachedScript) loadSha() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sha
+}
+
+func (s *cachedScript) storeSha(sha string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sha = sha
+}
+
+// eval runs the script against key with args, loading and caching its SHA
+// on first use or after the server has forgotten it.
+func (s *cachedScript) eval(ctx context.Context, key string, args ...string) error {
+	sha := s.loadSha()
+	cmd := s.client.Cmd.Evalsha().Sha1(sha).Numkeys(1).Key(key).Arg(args...).Build()
+	resp := s.client.Do(ctx, cmd)
+	if resp.Error() != nil && isNoScript(resp.Error()) {
+		loaded, err := s.client.Do(ctx, s.client.Cmd.ScriptLoad().Script(s.script).Build()).ToString()
+		if err != nil {
+			return err
+		}
+		s.storeSha(loaded)
+		cmd = s.client.Cmd.Evalsha().Sha1(loaded).Numkeys(1).Key(key).Arg(args...).Build()
+		resp = s.client.Do(ctx, cmd)
+	}
+	return resp.Error()
+}
+
+func isNoScript(err error) bool {
+	return err != nil && len(err.Error()) >= 8 && err.Error()[:8] == "NOSCRIPT"
+}
+
+// RetryStrategy decides how long Obtain should wait before its next
+// attempt, given the number of attempts made so far (starting at 1). A false
+// second return value means "give up".
+type RetryStrategy interface {
+	NextRetry(attempt int) (time.Duration, bool)
+}
+
+// LinearRetry retries every Interval until ctx is done.
+type LinearRetry struct {
+	Interval time.Duration
+}
+
+func (r LinearRetry) NextRetry(int) (time.Duration, bool) { return r.Interval, true }
+
+// ExponentialRetry doubles its delay (capped at Max) after every attempt.
+type ExponentialRetry struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (r ExponentialRetry) NextRetry(attempt int) (time.Duration, bool) {
+	d := r.Base << (attempt - 1)
+	// d>>(attempt-1) differs from Base once the shift has overflowed.
+	if r.Max > 0 && (d > r.Max || d>>(attempt-1) != r.Base) {
+		d = r.Max
+	}
+	return d, true
+}
+
+// LockerOption configures a Locker. A nil Retry means Obtain never retries:
+// it fails fast with ErrNotObtained if the key is already held.
+type LockerOption struct {
+	Retry RetryStrategy
+}
+
+// Locker obtains Locks against keys on the underlying client.
+type Locker struct {
+	client *client.SingleClient
+	retry  RetryStrategy
+
+	release *cachedScript
+	refresh *cachedScript
+}
+
+// NewLocker returns a Locker using option.
+func NewLocker(c *client.SingleClient, option LockerOption) *Locker {
+	return &Locker{
+		client:  c,
+		retry:   option.Retry,
+		release: &cachedScript{client: c, script: release},
+		refresh: &cachedScript{client: c, script: refresh},
+	}
+}
+
+// Lock is a held lock on a single key, obtained by Locker.Obtain.
+type Lock struct {
+	locker *Locker
+	key    string
+	token  string
+	fence  int64
+}
+
+// Fence returns the monotonically increasing fencing token issued when this
+// Lock was obtained. Callers writing to an external store alongside Redis
+// should reject any write tagged with a fencing token older than one they
+// have already seen, guarding against a paused/stale holder waking up after
+// losing the lock.
+func (l *Lock) Fence() int64 { return l.fence }
+
+// Obtain acquires the lock on key, retrying per the Locker's RetryStrategy
+// until it succeeds or ctx is done.
+func (l *Locker) Obtain(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		ok, err := l.client.Do(ctx, l.client.Cmd.Set().Key(key).Value(token).Nx().Px(ttl.Milliseconds()).Build()).ToString()
+		if err == nil && ok == "OK" {
+			fence, err := l.client.Do(ctx, l.client.Cmd.Incr().Key("locker:"+key+":fence").Build()).ToInt64()
+			if err != nil {
+				// The SET already succeeded, so the key is held in Redis: release
+				// it so the caller isn't left with no *Lock to release it with.
+				// Use a fresh context since ctx may itself be why the INCR failed.
+				(&Lock{locker: l, key: key, token: token}).Release(context.Background())
+				return nil, err
+			}
+			return &Lock{locker: l, key: key, token: token, fence: fence}, nil
+		}
+
+		if l.retry == nil {
+			return nil, ErrNotObtained
+		}
+		delay, retry := l.retry.NextRetry(attempt)
+		if !retry {
+			return nil, ErrNotObtained
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Release gives up the lock if it is still held by this Lock's token.
+func (l *Lock) Release(ctx context.Context) error {
+	return l.locker.release.eval(ctx, l.key, l.token)
+}
+
+// Refresh extends the lock's TTL to ttl if it is still held by this Lock's
+// token.
+func (l *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+	return l.locker.refresh.eval(ctx, l.key, l.token, ms)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,62 @@
+package lock
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsNoScript(t *testing.T) {
+	if isNoScript(nil) {
+		t.Fatal("nil error should not be a NOSCRIPT error")
+	}
+	if isNoScript(errors.New("WRONGTYPE not a string")) {
+		t.Fatal("non-NOSCRIPT error misclassified as NOSCRIPT")
+	}
+	if !isNoScript(errors.New("NOSCRIPT No matching script")) {
+		t.Fatal("NOSCRIPT-prefixed error not recognized")
+	}
+}
+
+func TestCachedScriptShaStartsEmpty(t *testing.T) {
+	s := &cachedScript{script: release}
+	if s.loadSha() != "" {
+		t.Fatal("a fresh cachedScript should have no cached SHA")
+	}
+	s.storeSha("deadbeef")
+	if s.loadSha() != "deadbeef" {
+		t.Fatalf("loadSha() = %q, want %q", s.loadSha(), "deadbeef")
+	}
+}
+
+func TestExponentialRetry(t *testing.T) {
+	r := ExponentialRetry{Base: 1, Max: 8}
+	cases := []struct {
+		attempt int
+		want    int64
+	}{
+		{1, 1},
+		{2, 2},
+		{3, 4},
+		{4, 8},
+		{5, 8}, // capped at Max
+	}
+	for _, c := range cases {
+		d, retry := r.NextRetry(c.attempt)
+		if !retry {
+			t.Fatalf("attempt %d: expected retry to continue", c.attempt)
+		}
+		if int64(d) != c.want {
+			t.Fatalf("attempt %d: delay = %d, want %d", c.attempt, int64(d), c.want)
+		}
+	}
+}
+
+func TestLinearRetry(t *testing.T) {
+	r := LinearRetry{Interval: 5}
+	for attempt := 1; attempt <= 3; attempt++ {
+		d, retry := r.NextRetry(attempt)
+		if !retry || d != 5 {
+			t.Fatalf("attempt %d: got (%v, %v), want (5, true)", attempt, d, retry)
+		}
+	}
+}
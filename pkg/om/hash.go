@@ -0,0 +1,84 @@
+// Package om ("object mapping") maps Go structs onto Redis storage and,
+// where the underlying module supports it, a searchable index.
+package om
+
+import (
+	"context"
+	"time"
+
+	"github.com/rueian/rueidis/internal/proto"
+)
+
+// HashRepository maps schema onto Redis hashes under prefix using
+// HSET/HGETALL, as built by SingleClient.NewHashRepository. If schema
+// declares `search` struct tags, it is also searchable via Search.
+type HashRepository struct {
+	prefix       string
+	schema       interface{}
+	doSave       func(ctx context.Context, key string, fields map[string]string) (ver int64, err error)
+	doFetch      func(ctx context.Context, key string) (map[string]proto.Message, error)
+	doFetchCache func(ctx context.Context, key string, ttl time.Duration) (map[string]proto.Message, error)
+	doDel        func(ctx context.Context, key string) (int64, error)
+	doSearch     func(ctx context.Context, query string, opt SearchOption) (total int64, docs []string, err error)
+}
+
+// NewHashRepository returns a HashRepository for schema, delegating the
+// actual HSET/HGETALL/DEL/FT.SEARCH round-trips to doSave/doFetch/
+// doFetchCache/doDel/doSearch so this package stays independent of any
+// particular client implementation. doSearch may be nil if schema has no
+// `search` tags, in which case Search returns ErrSearchNotConfigured.
+func NewHashRepository(
+	prefix string,
+	schema interface{},
+	doSave func(ctx context.Context, key string, fields map[string]string) (ver int64, err error),
+	doFetch func(ctx context.Context, key string) (map[string]proto.Message, error),
+	doFetchCache func(ctx context.Context, key string, ttl time.Duration) (map[string]proto.Message, error),
+	doDel func(ctx context.Context, key string) (int64, error),
+	doSearch func(ctx context.Context, query string, opt SearchOption) (total int64, docs []string, err error),
+) *HashRepository {
+	return &HashRepository{
+		prefix:       prefix,
+		schema:       schema,
+		doSave:       doSave,
+		doFetch:      doFetch,
+		doFetchCache: doFetchCache,
+		doDel:        doDel,
+		doSearch:     doSearch,
+	}
+}
+
+// Save writes fields to the hash identified by key and returns the int64
+// reported by doSave. The doSave wired up by SingleClient.NewHashRepository
+// always reports 0; no version counter is kept.
+func (r *HashRepository) Save(ctx context.Context, key string, fields map[string]string) (int64, error) {
+	return r.doSave(ctx, r.prefix+key, fields)
+}
+
+// Fetch reads the hash identified by key.
+func (r *HashRepository) Fetch(ctx context.Context, key string) (map[string]proto.Message, error) {
+	return r.doFetch(ctx, r.prefix+key)
+}
+
+// FetchCache behaves like Fetch but is served from the client-side cache
+// when possible.
+func (r *HashRepository) FetchCache(ctx context.Context, key string, ttl time.Duration) (map[string]proto.Message, error) {
+	return r.doFetchCache(ctx, r.prefix+key, ttl)
+}
+
+// Delete removes the hash identified by key.
+func (r *HashRepository) Delete(ctx context.Context, key string) (int64, error) {
+	return r.doDel(ctx, r.prefix+key)
+}
+
+// Search runs query against the RediSearch index backing this repository
+// and decodes the hits into *out (a pointer to a slice of schema's type).
+func (r *HashRepository) Search(ctx context.Context, query string, opt SearchOption, out interface{}) (SearchResult, error) {
+	if r.doSearch == nil {
+		return SearchResult{}, ErrSearchNotConfigured
+	}
+	total, docs, err := r.doSearch(ctx, query, opt)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return decodeHits(total, docs, opt, out)
+}
@@ -0,0 +1,89 @@
+package om
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// jsonTag is the struct tag used to map a field onto a RedisJSON path: the
+// tag's name, as encoding/json writes it, under the root, e.g. `json:"name"`
+// maps to "$.name". A field without the tag is mapped to "$.<FieldName>".
+const jsonTag = "json"
+
+// JSONRepository maps schema onto RedisJSON documents under prefix using
+// JSON.SET/JSON.GET, as built by SingleClient.NewJSONRepository. If schema
+// declares `search` struct tags, it is also searchable via Search.
+type JSONRepository struct {
+	prefix   string
+	schema   interface{}
+	doSave   func(ctx context.Context, key, path, value string) error
+	doFetch  func(ctx context.Context, key string) (string, error)
+	doDel    func(ctx context.Context, key string) (int64, error)
+	doSearch func(ctx context.Context, query string, opt SearchOption) (total int64, docs []string, err error)
+}
+
+// NewJSONRepository returns a JSONRepository for schema, delegating the
+// actual JSON.SET/JSON.GET/JSON.DEL/FT.SEARCH round-trips to doSave/
+// doFetch/doDel/doSearch so this package stays independent of any
+// particular client implementation. doSearch may be nil if schema has no
+// `search` tags, in which case Search returns ErrSearchNotConfigured.
+func NewJSONRepository(
+	prefix string,
+	schema interface{},
+	doSave func(ctx context.Context, key, path, value string) error,
+	doFetch func(ctx context.Context, key string) (string, error),
+	doDel func(ctx context.Context, key string) (int64, error),
+	doSearch func(ctx context.Context, query string, opt SearchOption) (total int64, docs []string, err error),
+) *JSONRepository {
+	return &JSONRepository{prefix: prefix, schema: schema, doSave: doSave, doFetch: doFetch, doDel: doDel, doSearch: doSearch}
+}
+
+// Save serializes entity as JSON and stores it at the document's root path.
+func (r *JSONRepository) Save(ctx context.Context, key string, entity interface{}) error {
+	raw, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	return r.doSave(ctx, r.prefix+key, "$", string(raw))
+}
+
+// Fetch reads the document identified by key and decodes it into out.
+func (r *JSONRepository) Fetch(ctx context.Context, key string, out interface{}) error {
+	raw, err := r.doFetch(ctx, r.prefix+key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(raw), out)
+}
+
+// Delete removes the document identified by key.
+func (r *JSONRepository) Delete(ctx context.Context, key string) (int64, error) {
+	return r.doDel(ctx, r.prefix+key)
+}
+
+// Search runs query against the RediSearch index backing this repository
+// and decodes the hits into *out (a pointer to a slice of schema's type).
+func (r *JSONRepository) Search(ctx context.Context, query string, opt SearchOption, out interface{}) (SearchResult, error) {
+	if r.doSearch == nil {
+		return SearchResult{}, ErrSearchNotConfigured
+	}
+	total, docs, err := r.doSearch(ctx, query, opt)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return decodeHits(total, docs, opt, out)
+}
+
+// jsonPath returns the RedisJSON path for the struct field f, honoring the
+// name in a `json:"name"` tag and otherwise defaulting to the field name,
+// so the path matches where Save's json.Marshal puts the field.
+func jsonPath(f reflect.StructField) string {
+	if tag, ok := f.Tag.Lookup(jsonTag); ok {
+		if name := strings.Split(tag, ",")[0]; name != "" {
+			return "$." + name
+		}
+	}
+	return "$." + f.Name
+}
@@ -0,0 +1,156 @@
+package om
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// searchTag is the struct tag describing how a field should be indexed by
+// RediSearch, e.g. `search:"text,sortable"`, `search:"numeric"`,
+// `search:"tag"` or `search:"vector,dim=768,distance=cosine"`.
+const searchTag = "search"
+
+// IndexOption configures the RediSearch index backing a repository.
+type IndexOption struct {
+	Prefix   string
+	Language string
+}
+
+// SearchOption controls paging of a Search call.
+type SearchOption struct {
+	Offset int
+	Limit  int
+}
+
+// SearchResult is the decoded, paged result of a Search call.
+type SearchResult struct {
+	Total  int64
+	Cursor int // offset to pass as SearchOption.Offset for the next page
+}
+
+// ErrSearchNotConfigured is returned by Search when the repository's schema
+// declared no `search` struct tags, so no RediSearch index backs it.
+var ErrSearchNotConfigured = errors.New("om: no search index configured for this schema")
+
+// fieldSpec describes one indexed field, parsed from a schema's `search`
+// struct tags.
+type fieldSpec struct {
+	path     string
+	typ      string // TAG, TEXT, NUMERIC or VECTOR
+	args     map[string]string
+	sortable bool
+}
+
+// parseFieldSpecs scans schema's fields for `search` tags.
+func parseFieldSpecs(schema interface{}) []fieldSpec {
+	t := reflect.TypeOf(schema)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var specs []fieldSpec
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup(searchTag)
+		if !ok {
+			continue
+		}
+		specs = append(specs, parseFieldSpec(jsonPath(f), tag))
+	}
+	return specs
+}
+
+// HasSearchFields reports whether schema declares any `search` tags, i.e.
+// whether a repository built from it should be given a RediSearch index.
+func HasSearchFields(schema interface{}) bool {
+	return len(parseFieldSpecs(schema)) > 0
+}
+
+// parseFieldSpec turns a `search:"text,sortable"`-style tag into a
+// fieldSpec. Recognized field types are tag, text, numeric and vector; a
+// vector type carries dim=N and distance=METRIC sub-arguments.
+func parseFieldSpec(path, tag string) fieldSpec {
+	parts := strings.Split(tag, ",")
+	spec := fieldSpec{path: path, typ: strings.ToUpper(parts[0]), args: map[string]string{}}
+	for _, opt := range parts[1:] {
+		if opt == "sortable" {
+			spec.sortable = true
+			continue
+		}
+		if kv := strings.SplitN(opt, "=", 2); len(kv) == 2 {
+			spec.args[kv[0]] = kv[1]
+		}
+	}
+	return spec
+}
+
+// CreateArgs builds the FT.CREATE argument list for indexName over storage
+// ("HASH" or "JSON"), from schema's `search` struct tags.
+func CreateArgs(indexName, storage string, schema interface{}, option IndexOption) []string {
+	args := []string{indexName, "ON", storage}
+	if option.Prefix != "" {
+		args = append(args, "PREFIX", "1", option.Prefix)
+	}
+	if option.Language != "" {
+		args = append(args, "LANGUAGE", option.Language)
+	}
+	args = append(args, "SCHEMA")
+	for _, f := range parseFieldSpecs(schema) {
+		args = append(args, f.path, "AS", strings.TrimPrefix(f.path, "$."), f.typ)
+		if f.sortable {
+			args = append(args, "SORTABLE")
+		}
+		if f.typ == "VECTOR" {
+			vecArgs := vectorArgs(f)
+			args = append(args, "HNSW", strconv.Itoa(len(vecArgs)))
+			args = append(args, vecArgs...)
+		}
+	}
+	return args
+}
+
+// vectorArgs renders a vector field's tag sub-arguments as the flat
+// TYPE/DIM/DISTANCE_METRIC attribute list RediSearch's FT.CREATE expects,
+// e.g. `search:"vector,dim=768,distance=cosine"` becomes
+// ["TYPE", "FLOAT32", "DIM", "768", "DISTANCE_METRIC", "COSINE"].
+func vectorArgs(f fieldSpec) []string {
+	keys := make([]string, 0, len(f.args))
+	for k := range f.args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := []string{"TYPE", "FLOAT32"}
+	for _, k := range keys {
+		args = append(args, vectorAttrName(k), strings.ToUpper(f.args[k]))
+	}
+	return args
+}
+
+// vectorAttrName maps a tag sub-argument key to its FT.CREATE attribute
+// name; most map to their own uppercased name except "distance", which
+// RediSearch spells DISTANCE_METRIC.
+func vectorAttrName(k string) string {
+	if k == "distance" {
+		return "DISTANCE_METRIC"
+	}
+	return strings.ToUpper(k)
+}
+
+// decodeHits decodes RediSearch document bodies into *out, a pointer to a
+// slice of the repository's schema type.
+func decodeHits(total int64, docs []string, opt SearchOption, out interface{}) (SearchResult, error) {
+	slice := reflect.ValueOf(out).Elem()
+	elemType := slice.Type().Elem()
+	for _, doc := range docs {
+		v := reflect.New(elemType)
+		if err := json.Unmarshal([]byte(doc), v.Interface()); err != nil {
+			return SearchResult{}, err
+		}
+		slice.Set(reflect.Append(slice, v.Elem()))
+	}
+	return SearchResult{Total: total, Cursor: opt.Offset + len(docs)}, nil
+}
@@ -0,0 +1,65 @@
+package om
+
+import (
+	"reflect"
+	"testing"
+)
+
+type vectorSchema struct {
+	Name string `json:"name" search:"tag"`
+	Vec  []byte `json:"vec" search:"vector,dim=768,distance=cosine"`
+}
+
+func TestCreateArgsVectorField(t *testing.T) {
+	args := CreateArgs("idx:doc", "JSON", vectorSchema{}, IndexOption{Prefix: "doc:"})
+
+	joined := args
+	vecAt := indexOf(joined, "HNSW")
+	if vecAt == -1 {
+		t.Fatalf("expected HNSW in args, got %v", args)
+	}
+
+	count, rest := joined[vecAt+1], joined[vecAt+2:]
+	if count != "6" {
+		t.Fatalf("expected HNSW attribute count 6 (3 pairs), got %v", count)
+	}
+	want := []string{"TYPE", "FLOAT32", "DIM", "768", "DISTANCE_METRIC", "COSINE"}
+	if !reflect.DeepEqual(rest[:6], want) {
+		t.Fatalf("vector args = %v, want %v", rest[:6], want)
+	}
+}
+
+func TestHasSearchFields(t *testing.T) {
+	if HasSearchFields(struct{ Name string }{}) {
+		t.Fatal("schema with no search tags should report false")
+	}
+	if !HasSearchFields(vectorSchema{}) {
+		t.Fatal("schema with search tags should report true")
+	}
+}
+
+func TestDecodeHits(t *testing.T) {
+	type out struct {
+		Name string `json:"name"`
+	}
+	var dst []out
+	res, err := decodeHits(2, []string{`{"name":"a"}`, `{"name":"b"}`}, SearchOption{Offset: 0, Limit: 2}, &dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 2 || res.Cursor != 2 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if len(dst) != 2 || dst[0].Name != "a" || dst[1].Name != "b" {
+		t.Fatalf("unexpected decoded hits %+v", dst)
+	}
+}
+
+func indexOf(s []string, v string) int {
+	for i, e := range s {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,149 @@
+// Package rate implements a leaky-bucket rate limiter enforced atomically
+// on the Redis server via a cached Lua script, so the limit holds across
+// any number of client processes sharing the same key.
+package rate
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rueian/rueidis/internal/proto"
+	"github.com/rueian/rueidis/pkg/client"
+)
+
+// leakyBucket stores {level, last_ts} in a hash per key and leaks based on
+// the server's own clock (TIME), so clock skew between callers can't let
+// the limit be exceeded. KEYS[1] is the bucket key, ARGV[1] is capacity,
+// ARGV[2] is the leak rate per second, ARGV[3] is the requested cost n.
+const leakyBucket = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local now = redis.call('TIME')
+local ts = tonumber(now[1]) + tonumber(now[2]) / 1e6
+
+local bucket = redis.call('HMGET', KEYS[1], 'level', 'last_ts')
+local level = tonumber(bucket[1]) or 0
+local last = tonumber(bucket[2]) or ts
+
+level = math.max(0, level - rate * (ts - last))
+
+local allowed = 0
+local retry = 0
+if level + n <= capacity then
+	level = level + n
+	allowed = 1
+else
+	retry = (level + n - capacity) / rate
+end
+
+redis.call('HSET', KEYS[1], 'level', level, 'last_ts', ts)
+redis.call('EXPIRE', KEYS[1], math.ceil(capacity / rate) + 1)
+
+return {allowed, math.floor(capacity - level), tostring(retry)}
+`
+
+// Limiter enforces a rate/per leaky-bucket limit per key, shared by every
+// caller that goes through the same Redis key.
+type Limiter struct {
+	client   *client.SingleClient
+	name     string
+	capacity float64 // burst size: the bucket may hold up to `rate` units
+	leakRate float64 // units leaked per second, i.e. rate/per
+
+	shaMu sync.RWMutex
+	sha   string
+}
+
+func (l *Limiter) loadSha() string {
+	l.shaMu.RLock()
+	defer l.shaMu.RUnlock()
+	return l.sha
+}
+
+func (l *Limiter) storeSha(sha string) {
+	l.shaMu.Lock()
+	defer l.shaMu.Unlock()
+	l.sha = sha
+}
+
+// NewLimiter returns a Limiter allowing up to rate requests per per,
+// identified on the server by name (each distinct name/key pair gets its
+// own bucket). The Lua script backing the limiter is loaded lazily on the
+// first Allow call and its SHA cached for EVALSHA thereafter.
+func NewLimiter(c *client.SingleClient, name string, rate int, per time.Duration) *Limiter {
+	return &Limiter{
+		client:   c,
+		name:     name,
+		capacity: float64(rate),
+		leakRate: float64(rate) / per.Seconds(),
+	}
+}
+
+// Allow is equivalent to AllowN(ctx, key, 1).
+func (l *Limiter) Allow(ctx context.Context, key string) (allowed bool, remaining int, retryAfter time.Duration, err error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN reports whether n units may be consumed from the bucket for key
+// right now. If not, retryAfter estimates how long the caller should wait
+// before the bucket has leaked enough to admit the request.
+func (l *Limiter) AllowN(ctx context.Context, key string, n int) (allowed bool, remaining int, retryAfter time.Duration, err error) {
+	bucket := l.name + ":" + key
+	arr, err := l.eval(ctx, bucket, n)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	retrySeconds, err := arr[2].ToString()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	allowedN, _ := arr[0].ToInt64()
+	remainingN, _ := arr[1].ToInt64()
+	retry, err := parseRetry(retrySeconds)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return allowedN == 1, int(remainingN), retry, nil
+}
+
+// parseRetry converts the Lua script's tostring()-rendered retry-after
+// seconds into a Duration. Lua renders small floats in scientific notation
+// (e.g. "1e-05"), which time.ParseDuration cannot parse, so this goes
+// through strconv.ParseFloat instead.
+func parseRetry(seconds string) (time.Duration, error) {
+	s, err := strconv.ParseFloat(seconds, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(s * float64(time.Second)), nil
+}
+
+func (l *Limiter) eval(ctx context.Context, bucket string, n int) ([]proto.Result, error) {
+	capacity := strconv.FormatFloat(l.capacity, 'f', -1, 64)
+	rate := strconv.FormatFloat(l.leakRate, 'f', -1, 64)
+	cost := strconv.Itoa(n)
+
+	sha := l.loadSha()
+	cmd := l.client.Cmd.Evalsha().Sha1(sha).Numkeys(1).Key(bucket).Arg(capacity, rate, cost).Build()
+	resp := l.client.Do(ctx, cmd)
+	if resp.Error() != nil && isNoScript(resp.Error()) {
+		loaded, err := l.client.Do(ctx, l.client.Cmd.ScriptLoad().Script(leakyBucket).Build()).ToString()
+		if err != nil {
+			return nil, err
+		}
+		l.storeSha(loaded)
+		cmd = l.client.Cmd.Evalsha().Sha1(loaded).Numkeys(1).Key(bucket).Arg(capacity, rate, cost).Build()
+		resp = l.client.Do(ctx, cmd)
+	}
+	if resp.Error() != nil {
+		return nil, resp.Error()
+	}
+	return resp.ToArray()
+}
+
+func isNoScript(err error) bool {
+	return err != nil && len(err.Error()) >= 8 && err.Error()[:8] == "NOSCRIPT"
+}
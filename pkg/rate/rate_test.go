@@ -0,0 +1,32 @@
+package rate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetry(t *testing.T) {
+	cases := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"0", 0},
+		{"1.5", 1500 * time.Millisecond},
+		{"1e-05", 10 * time.Microsecond},
+	}
+	for _, c := range cases {
+		got, err := parseRetry(c.in)
+		if err != nil {
+			t.Fatalf("parseRetry(%q) returned error: %v", c.in, err)
+		}
+		if got != c.want {
+			t.Fatalf("parseRetry(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseRetryInvalid(t *testing.T) {
+	if _, err := parseRetry("not-a-number"); err == nil {
+		t.Fatal("expected an error for an unparseable retry value")
+	}
+}
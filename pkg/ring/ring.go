@@ -0,0 +1,271 @@
+// Package ring provides RingClient, a client that shards keys across
+// several independent (non-cluster) Redis servers using a consistent hash
+// ring, rather than relying on Redis Cluster for sharding.
+package ring
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rueian/rueidis/internal/cmds"
+	"github.com/rueian/rueidis/internal/hashring"
+	"github.com/rueian/rueidis/internal/proto"
+	"github.com/rueian/rueidis/pkg/client"
+	"github.com/rueian/rueidis/pkg/conn"
+	"github.com/rueian/rueidis/pkg/om"
+)
+
+// ErrNoShard is returned when the ring currently holds no shard, e.g.
+// because every shard was drained for being undialable. Keys of a single
+// drained shard are routed to the remaining shards instead.
+var ErrNoShard = errors.New("ring: no shard for key")
+
+// RingOption configures a RingClient. Shards maps each shard's address,
+// which is also its name on the ring, to the conn.Option used to dial it.
+type RingOption struct {
+	Shards map[string]conn.Option
+}
+
+type shard struct {
+	name   string
+	client *client.SingleClient
+}
+
+// RingClient shards commands across the shards in RingOption by hashing
+// the command's key with a consistent hash ring, so that adding or
+// removing a shard only remaps roughly 1/N of the keyspace.
+type RingClient struct {
+	Cmd *cmds.Builder
+
+	mu     sync.RWMutex
+	ring   *hashring.Ring
+	shards map[string]*shard
+
+	closed chan struct{}
+}
+
+// NewRingClient dials every shard in option and returns a RingClient ready
+// to route commands across them.
+func NewRingClient(option RingOption) (*RingClient, error) {
+	rc := &RingClient{
+		Cmd:    cmds.NewBuilder(),
+		shards: make(map[string]*shard, len(option.Shards)),
+		closed: make(chan struct{}),
+	}
+	names := make([]string, 0, len(option.Shards))
+	for name, opt := range option.Shards {
+		c, err := client.NewSingleClient(client.SingleClientOption{Address: name, ConnOption: opt})
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		rc.shards[name] = &shard{name: name, client: c}
+		names = append(names, name)
+	}
+	rc.ring = hashring.New(names)
+	go rc.watch()
+	return rc, nil
+}
+
+// watch periodically drops unhealthy shards from the ring and restores them
+// once they are dialable again, so traffic drains away from a failing shard
+// without the caller having to notice. It stops when the RingClient is
+// closed.
+func (rc *RingClient) watch() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rc.closed:
+			return
+		case <-ticker.C:
+		}
+
+		rc.mu.RLock()
+		shards := make([]*shard, 0, len(rc.shards))
+		for _, s := range rc.shards {
+			shards = append(shards, s)
+		}
+		rc.mu.RUnlock()
+
+		healthy := make([]string, 0, len(shards))
+		for _, s := range shards {
+			if s.client.Dialable() == nil {
+				healthy = append(healthy, s.name)
+			}
+		}
+		sort.Strings(healthy)
+
+		rc.mu.Lock()
+		rc.ring.Rebuild(healthy)
+		rc.mu.Unlock()
+	}
+}
+
+func (rc *RingClient) shardFor(key string) *shard {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	name := rc.ring.Locate(key)
+	return rc.shards[name]
+}
+
+// Do routes cmd to the shard owning its key and returns its response. If
+// cmd has no key, it is fanned out to every shard via fanOut. err is
+// ErrNoShard if the owning shard is currently missing from the ring.
+func (rc *RingClient) Do(ctx context.Context, cmd cmds.Completed) (resp proto.Result, err error) {
+	if key, ok := firstKey(cmd); ok {
+		s := rc.shardFor(key)
+		if s == nil {
+			return resp, ErrNoShard
+		}
+		return s.client.Do(ctx, cmd), nil
+	}
+	return rc.fanOut(ctx, cmd), nil
+}
+
+// fanOut dispatches cmd to every shard, used for commands with no key such
+// as INFO or FLUSHALL. It deterministically returns the first non-error
+// response, or the last shard's (error-bearing) response if every shard
+// failed.
+func (rc *RingClient) fanOut(ctx context.Context, cmd cmds.Completed) (resp proto.Result) {
+	rc.mu.RLock()
+	shards := make([]*shard, 0, len(rc.shards))
+	for _, s := range rc.shards {
+		shards = append(shards, s)
+	}
+	rc.mu.RUnlock()
+	sort.Slice(shards, func(i, j int) bool { return shards[i].name < shards[j].name })
+
+	if len(shards) == 0 {
+		return resp
+	}
+
+	results := make([]proto.Result, len(shards))
+	wg := sync.WaitGroup{}
+	wg.Add(len(shards))
+	for i, s := range shards {
+		i, s := i, s
+		go func() {
+			defer wg.Done()
+			results[i] = s.client.Do(ctx, cmd)
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		if r.Error() == nil {
+			return r
+		}
+	}
+	return results[len(results)-1]
+}
+
+// DoMulti splits multi by the shard each command's key hashes to, sends
+// each shard its sub-batch concurrently with DoMulti, fans out any keyless
+// command the same way Do does, and stitches the responses back together in
+// multi's original order. err is non-nil if any key's owning shard was
+// missing from the ring; the corresponding entries in resp are left as the
+// zero value.
+func (rc *RingClient) DoMulti(ctx context.Context, multi ...cmds.Completed) (resp []proto.Result, err error) {
+	resp = make([]proto.Result, len(multi))
+	byShard := make(map[*shard][]int, len(rc.shards))
+	var keyless []int
+	for i, cmd := range multi {
+		if key, ok := firstKey(cmd); ok {
+			s := rc.shardFor(key)
+			byShard[s] = append(byShard[s], i)
+		} else {
+			keyless = append(keyless, i)
+		}
+	}
+
+	var errMu sync.Mutex
+	wg := sync.WaitGroup{}
+	wg.Add(len(byShard) + len(keyless))
+	for s, idx := range byShard {
+		s, idx := s, idx
+		go func() {
+			defer wg.Done()
+			if s == nil {
+				errMu.Lock()
+				if err == nil {
+					err = ErrNoShard
+				}
+				errMu.Unlock()
+				return
+			}
+			sub := make([]cmds.Completed, len(idx))
+			for i, j := range idx {
+				sub[i] = multi[j]
+			}
+			results := s.client.DoMulti(ctx, sub...)
+			for i, j := range idx {
+				resp[j] = results[i]
+			}
+		}()
+	}
+	for _, j := range keyless {
+		j := j
+		go func() {
+			defer wg.Done()
+			resp[j] = rc.fanOut(ctx, multi[j])
+		}()
+	}
+	wg.Wait()
+	return resp, err
+}
+
+// DoCache routes cmd to the shard owning its key and serves/fills that
+// shard's client-side cache. err is ErrNoShard if the owning shard is
+// currently missing from the ring.
+func (rc *RingClient) DoCache(ctx context.Context, cmd cmds.Cacheable, ttl time.Duration) (resp proto.Result, err error) {
+	key, ok := firstKey(cmds.Completed(cmd))
+	if !ok {
+		return resp, ErrNoShard
+	}
+	s := rc.shardFor(key)
+	if s == nil {
+		return resp, ErrNoShard
+	}
+	return s.client.DoCache(ctx, cmd, ttl), nil
+}
+
+// DedicatedWire acquires a dedicated wire on the shard owning key and runs
+// fn against it.
+func (rc *RingClient) DedicatedWire(ctx context.Context, key string, fn func(client.DedicatedSingleClient) error) error {
+	s := rc.shardFor(key)
+	if s == nil {
+		return ErrNoShard
+	}
+	return s.client.DedicatedWire(ctx, fn)
+}
+
+// NewHashRepository creates an om.HashRepository backed by the shard owning
+// prefix, so every entity under that prefix lives on the same shard.
+func (rc *RingClient) NewHashRepository(prefix string, schema interface{}) (*om.HashRepository, error) {
+	s := rc.shardFor(prefix)
+	if s == nil {
+		return nil, ErrNoShard
+	}
+	return s.client.NewHashRepository(prefix, schema), nil
+}
+
+// Close stops the background health watcher and closes every shard's
+// connection.
+func (rc *RingClient) Close() {
+	close(rc.closed)
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	for _, s := range rc.shards {
+		s.client.Close()
+	}
+}
+
+// firstKey returns the first key argument of cmd, using the slot/hashtag
+// logic already applied by the Builder when the command was constructed.
+func firstKey(cmd cmds.Completed) (string, bool) {
+	return cmd.FirstKey()
+}
@@ -0,0 +1,44 @@
+package ring
+
+import (
+	"testing"
+
+	"github.com/rueian/rueidis/internal/hashring"
+)
+
+// newTestRingClient builds a RingClient with shards that carry no dialable
+// client, enough to exercise shardFor's pure ring-routing logic without a
+// live Redis connection.
+func newTestRingClient(names ...string) *RingClient {
+	rc := &RingClient{shards: make(map[string]*shard, len(names)), closed: make(chan struct{})}
+	for _, n := range names {
+		rc.shards[n] = &shard{name: n}
+	}
+	rc.ring = hashring.New(names)
+	return rc
+}
+
+func TestShardForConsistentWithRingLocate(t *testing.T) {
+	rc := newTestRingClient("a", "b", "c")
+	for _, key := range []string{"user:1", "user:2", "{tag}:x", "{tag}:y"} {
+		want := rc.ring.Locate(key)
+		got := rc.shardFor(key)
+		if got == nil || got.name != want {
+			t.Fatalf("shardFor(%q) = %v, want shard %q", key, got, want)
+		}
+	}
+}
+
+func TestShardForEmptyRing(t *testing.T) {
+	rc := newTestRingClient()
+	if s := rc.shardFor("any"); s != nil {
+		t.Fatalf("shardFor on empty ring = %v, want nil", s)
+	}
+}
+
+func TestShardForHashtagGrouping(t *testing.T) {
+	rc := newTestRingClient("a", "b", "c", "d")
+	if rc.shardFor("{order}:1").name != rc.shardFor("{order}:2").name {
+		t.Fatal("keys sharing a hashtag must land on the same shard")
+	}
+}
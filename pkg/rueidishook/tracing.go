@@ -0,0 +1,84 @@
+// Package rueidishook provides built-in conn.Hook implementations for
+// observability: an OpenTelemetry tracing hook and a Prometheus metrics
+// hook.
+package rueidishook
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rueian/rueidis/internal/cmds"
+	"github.com/rueian/rueidis/internal/proto"
+	"github.com/rueian/rueidis/pkg/conn"
+)
+
+type spanKey struct{}
+
+// TracingHook is a conn.Hook that starts one span per command, named after
+// the command's first token (e.g. "GET", "HSET"), and one span named
+// "pipeline" around each pipeline.
+type TracingHook struct {
+	tracer trace.Tracer
+}
+
+// NewTracingHook returns a TracingHook using the given tracer name, or
+// "rueidis" if empty.
+func NewTracingHook(tracerName string) *TracingHook {
+	if tracerName == "" {
+		tracerName = "rueidis"
+	}
+	return &TracingHook{tracer: otel.Tracer(tracerName)}
+}
+
+var _ conn.Hook = (*TracingHook)(nil)
+
+func (h *TracingHook) BeforeProcess(ctx context.Context, cmd cmds.Completed) (context.Context, error) {
+	ctx, span := h.tracer.Start(ctx, commandName(cmd))
+	return context.WithValue(ctx, spanKey{}, span), nil
+}
+
+func (h *TracingHook) AfterProcess(ctx context.Context, _ cmds.Completed, resp proto.Result) error {
+	endSpan(ctx, resp.Error())
+	return nil
+}
+
+func (h *TracingHook) BeforeProcessPipeline(ctx context.Context, cs []cmds.Completed) (context.Context, error) {
+	ctx, span := h.tracer.Start(ctx, "pipeline")
+	span.SetAttributes(attribute.Int("rueidis.pipeline.size", len(cs)))
+	return context.WithValue(ctx, spanKey{}, span), nil
+}
+
+func (h *TracingHook) AfterProcessPipeline(ctx context.Context, _ []cmds.Completed, resp []proto.Result) error {
+	var err error
+	for _, r := range resp {
+		if e := r.Error(); e != nil {
+			err = e
+			break
+		}
+	}
+	endSpan(ctx, err)
+	return nil
+}
+
+func endSpan(ctx context.Context, err error) {
+	span, ok := ctx.Value(spanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func commandName(cmd cmds.Completed) string {
+	if tokens := cmd.Commands(); len(tokens) > 0 {
+		return tokens[0]
+	}
+	return "unknown"
+}